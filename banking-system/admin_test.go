@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireAdminRejectsMissingOrWrongCredentials(t *testing.T) {
+	t.Setenv("ADMIN_USERNAME", "admin")
+	t.Setenv("ADMIN_PASSWORD", "secret")
+
+	called := false
+	handler := requireAdmin(func(w http.ResponseWriter, r *http.Request) { called = true })
+
+	r := httptest.NewRequest(http.MethodGet, "/admin/accounts", nil)
+	w := httptest.NewRecorder()
+	handler(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("no credentials: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/admin/accounts", nil)
+	r.SetBasicAuth("admin", "wrong-password")
+	w = httptest.NewRecorder()
+	handler(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong password: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	if called {
+		t.Error("next handler ran despite failing authentication")
+	}
+}
+
+func TestRequireAdminRejectsEmptyConfiguredCredentials(t *testing.T) {
+	t.Setenv("ADMIN_USERNAME", "")
+	t.Setenv("ADMIN_PASSWORD", "")
+
+	handler := requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("next handler ran despite ADMIN_USERNAME/ADMIN_PASSWORD being unset")
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/admin/accounts", nil)
+	r.SetBasicAuth("", "")
+	w := httptest.NewRecorder()
+	handler(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestRequireAdminAcceptsCorrectCredentials(t *testing.T) {
+	t.Setenv("ADMIN_USERNAME", "admin")
+	t.Setenv("ADMIN_PASSWORD", "secret")
+
+	called := false
+	handler := requireAdmin(func(w http.ResponseWriter, r *http.Request) { called = true })
+
+	r := httptest.NewRequest(http.MethodGet, "/admin/accounts", nil)
+	r.SetBasicAuth("admin", "secret")
+	w := httptest.NewRecorder()
+	handler(w, r)
+
+	if !called {
+		t.Error("next handler did not run despite correct credentials")
+	}
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+// TestFreezeBlocksDepositAndWithdraw checks that setAccountFrozen's Frozen
+// flag, once persisted through repo.Save, is enforced by Deposit/Withdraw
+// on the next load - the freeze feature's whole point.
+func TestFreezeBlocksDepositAndWithdraw(t *testing.T) {
+	repo = NewInMemoryRepository()
+	repo.Save("alice", newTestAccount("alice", 1000))
+
+	r := httptest.NewRequest(http.MethodPost, "/admin/accounts/alice/freeze", nil)
+	w := httptest.NewRecorder()
+	setAccountFrozen(w, r, "alice", true)
+	if w.Code != http.StatusOK {
+		t.Fatalf("freeze: status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	alice, _ := repo.Get("alice")
+	if err := alice.Deposit(100); err == nil {
+		t.Error("expected Deposit to fail on a frozen account, got nil")
+	}
+	if err := alice.Withdraw(100); err == nil {
+		t.Error("expected Withdraw to fail on a frozen account, got nil")
+	}
+	if got, want := alice.CheckBalance(), 1000.0; got != want {
+		t.Errorf("balance = %.2f, want %.2f (frozen account must reject both)", got, want)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/admin/accounts/alice/unfreeze", nil)
+	w = httptest.NewRecorder()
+	setAccountFrozen(w, r, "alice", false)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unfreeze: status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	alice, _ = repo.Get("alice")
+	if err := alice.Deposit(100); err != nil {
+		t.Errorf("expected Deposit to succeed after unfreeze, got %v", err)
+	}
+}
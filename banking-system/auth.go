@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Session and challenge tuning
+const (
+	sessionCookieName         = "session"
+	sessionTTL                = 24 * time.Hour
+	defaultTwoFactorThreshold = 1000.00
+	challengeTTL              = 5 * time.Minute
+)
+
+// twoFactorThreshold is the withdrawal amount above which a 2FA challenge is
+// required. It defaults to defaultTwoFactorThreshold and is overridden by
+// loadTwoFactorThreshold from TWO_FACTOR_THRESHOLD at startup.
+var twoFactorThreshold = defaultTwoFactorThreshold
+
+// loadTwoFactorThreshold reads TWO_FACTOR_THRESHOLD so deployments can tune
+// the 2FA cutoff without a code change, mirroring how initRepository reads
+// STORAGE_BACKEND.
+func loadTwoFactorThreshold() float64 {
+	v := os.Getenv("TWO_FACTOR_THRESHOLD")
+	if v == "" {
+		return defaultTwoFactorThreshold
+	}
+	parsed, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return defaultTwoFactorThreshold
+	}
+	return parsed
+}
+
+// Registered Users
+type User struct {
+	Name         string
+	PasswordHash string
+}
+
+var (
+	users      = make(map[string]*User)
+	usersMutex sync.Mutex
+)
+
+// sessionSecret signs session cookies; it is regenerated on every process
+// start, which is acceptable since sessions are meant to be short-lived.
+var sessionSecret = generateSecret()
+
+// Pending Two-Factor Operations
+type pendingOp struct {
+	Kind      string
+	Name      string
+	Amount    float64
+	Code      string
+	ExpiresAt time.Time
+	OpID      string
+}
+
+var (
+	pendingOps      = make(map[string]pendingOp)
+	pendingOpsMutex sync.Mutex
+)
+
+func generateSecret() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic("failed to generate session secret: " + err.Error())
+	}
+	return secret
+}
+
+// hashPassword salts and hashes a password with bcrypt's adaptive work
+// factor, unlike a bare SHA-256 digest which is fast to brute-force.
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func checkPassword(password, hash string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// signSession builds an HMAC-signed "name:expiry:signature" token.
+func signSession(name string) string {
+	expiry := time.Now().Add(sessionTTL).Unix()
+	payload := fmt.Sprintf("%s:%d", name, expiry)
+	mac := hmac.New(sha256.New, sessionSecret)
+	mac.Write([]byte(payload))
+	signature := hex.EncodeToString(mac.Sum(nil))
+	return base64.URLEncoding.EncodeToString([]byte(payload + ":" + signature))
+}
+
+// verifySession checks the HMAC signature and expiry and returns the
+// account name the token was issued for.
+func verifySession(token string) (string, error) {
+	decoded, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return "", errors.New("invalid session token")
+	}
+	parts := strings.SplitN(string(decoded), ":", 3)
+	if len(parts) != 3 {
+		return "", errors.New("malformed session token")
+	}
+	name, expiryStr, signature := parts[0], parts[1], parts[2]
+
+	mac := hmac.New(sha256.New, sessionSecret)
+	mac.Write([]byte(name + ":" + expiryStr))
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return "", errors.New("invalid session signature")
+	}
+
+	expiry, err := strconv.ParseInt(expiryStr, 10, 64)
+	if err != nil {
+		return "", errors.New("malformed session expiry")
+	}
+	if time.Now().Unix() > expiry {
+		return "", errors.New("session expired")
+	}
+	return name, nil
+}
+
+type contextKey string
+
+const accountNameKey contextKey = "accountName"
+
+// requireAuth resolves the caller's account from the session cookie and
+// rejects the request if it is missing, malformed, or expired, instead of
+// trusting a client-supplied name field.
+func requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(sessionCookieName)
+		if err != nil {
+			http.Error(w, "Not authenticated", http.StatusUnauthorized)
+			return
+		}
+		name, err := verifySession(cookie.Value)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), accountNameKey, name)))
+	}
+}
+
+func accountNameFromRequest(r *http.Request) string {
+	name, _ := r.Context().Value(accountNameKey).(string)
+	return name
+}
+
+// Register Handler
+func registerHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("name")
+	password := r.FormValue("password")
+	if name == "" || password == "" {
+		sendResponse(w, "Name and password are required")
+		return
+	}
+
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+
+	if _, exists := users[name]; exists {
+		sendResponse(w, "User already exists")
+		return
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		http.Error(w, "Could not create user", http.StatusInternalServerError)
+		return
+	}
+	users[name] = &User{Name: name, PasswordHash: hash}
+	sendResponse(w, "Registration successful")
+}
+
+// Login Handler
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("name")
+	password := r.FormValue("password")
+
+	usersMutex.Lock()
+	user, exists := users[name]
+	usersMutex.Unlock()
+
+	if !exists || !checkPassword(password, user.PasswordHash) {
+		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    signSession(name),
+		Expires:  time.Now().Add(sessionTTL),
+		HttpOnly: true,
+		Path:     "/",
+	})
+	sendResponse(w, "Login successful")
+}
+
+func generateChallengeCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+func generateChallengeID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// beginTwoFactorChallenge stores a pending operation and issues a one-time
+// code the caller must present to /confirm to complete it.
+func beginTwoFactorChallenge(kind, name string, amount float64, opID string) (string, error) {
+	challengeID, err := generateChallengeID()
+	if err != nil {
+		return "", err
+	}
+	code, err := generateChallengeCode()
+	if err != nil {
+		return "", err
+	}
+
+	pendingOpsMutex.Lock()
+	pendingOps[challengeID] = pendingOp{
+		Kind:      kind,
+		Name:      name,
+		Amount:    amount,
+		Code:      code,
+		ExpiresAt: time.Now().Add(challengeTTL),
+		OpID:      opID,
+	}
+	pendingOpsMutex.Unlock()
+
+	// Stand-in for an SMS/email provider until one is wired up.
+	fmt.Printf("2FA code for %s: %s (challenge %s)\n", name, code, challengeID)
+	return challengeID, nil
+}
+
+// Confirm Handler - completes a pending operation once the caller proves
+// possession of the one-time code from beginTwoFactorChallenge.
+func confirmHandler(w http.ResponseWriter, r *http.Request) {
+	challengeID := r.FormValue("challengeId")
+	code := r.FormValue("code")
+
+	pendingOpsMutex.Lock()
+	pending, exists := pendingOps[challengeID]
+	if exists {
+		delete(pendingOps, challengeID)
+	}
+	pendingOpsMutex.Unlock()
+
+	if !exists {
+		sendResponse(w, "Challenge not found or already used")
+		return
+	}
+
+	var operation *Operation
+	if value, exists := operations.Load(pending.OpID); exists {
+		operation = value.(*Operation)
+	}
+
+	if time.Now().After(pending.ExpiresAt) {
+		if operation != nil {
+			transitionOperation(operation, OpRejected, errors.New("challenge expired"))
+		}
+		sendResponse(w, "Challenge expired")
+		return
+	}
+	if code != pending.Code {
+		if operation != nil {
+			transitionOperation(operation, OpRejected, errors.New("incorrect code"))
+		}
+		sendResponse(w, "Incorrect code")
+		return
+	}
+
+	if _, exists := repo.Get(pending.Name); !exists {
+		if operation != nil {
+			transitionOperation(operation, OpRejected, errors.New("account not found"))
+		}
+		sendResponse(w, "Account not found")
+		return
+	}
+
+	switch pending.Kind {
+	case "withdraw":
+		// Claim the operation before moving any money: if it was already
+		// cancelled via /op/{id}/cancel (or otherwise moved out of
+		// Pending), transitionIfPending fails and the withdrawal never
+		// happens.
+		if operation != nil && !operation.transitionIfPending(OpConfirmed, nil) {
+			sendResponse(w, fmt.Sprintf("Operation is %s and can no longer be confirmed", operation.snapshot().State))
+			return
+		}
+
+		// Mutate holds the repository lock across the whole read-modify-write
+		// so a confirmed withdrawal can't race a concurrent one on the same
+		// account.
+		var newBalance float64
+		err := repo.Mutate(pending.Name, func(acc Account) error {
+			if err := acc.Withdraw(pending.Amount); err != nil {
+				return err
+			}
+			newBalance = acc.CheckBalance()
+			return nil
+		})
+		if err != nil {
+			if operation != nil {
+				transitionOperation(operation, OpRejected, err)
+			}
+			sendResponse(w, err.Error())
+			return
+		}
+		repo.AppendHistory(pending.Name, Transaction{
+			ID:           newTransactionID(),
+			Time:         time.Now(),
+			Kind:         KindWithdraw,
+			Amount:       pending.Amount,
+			BalanceAfter: newBalance,
+		})
+		if operation != nil {
+			transitionOperation(operation, OpConfirmed, nil)
+		}
+		sendResponse(w, fmt.Sprintf("Withdrawal successful! New Balance: $%.2f", newBalance))
+	default:
+		sendResponse(w, "Unknown challenge type")
+	}
+}
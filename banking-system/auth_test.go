@@ -0,0 +1,184 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// confirmRequest POSTs to confirmHandler with the given challenge ID and
+// code, the same form fields the /confirm endpoint expects.
+func confirmRequest(t *testing.T, challengeID, code string) {
+	t.Helper()
+	form := url.Values{"challengeId": {challengeID}, "code": {code}}
+	r := httptest.NewRequest(http.MethodPost, "/confirm", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	confirmHandler(httptest.NewRecorder(), r)
+}
+
+// signSessionWithExpiry builds a session token the same way signSession
+// does, but with a caller-chosen expiry, so tests can exercise expiry
+// without sleeping through sessionTTL.
+func signSessionWithExpiry(name string, expiry time.Time) string {
+	payload := fmt.Sprintf("%s:%d", name, expiry.Unix())
+	mac := hmac.New(sha256.New, sessionSecret)
+	mac.Write([]byte(payload))
+	signature := hex.EncodeToString(mac.Sum(nil))
+	return base64.URLEncoding.EncodeToString([]byte(payload + ":" + signature))
+}
+
+func TestSignSessionVerifySessionRoundTrip(t *testing.T) {
+	token := signSession("alice")
+
+	name, err := verifySession(token)
+	if err != nil {
+		t.Fatalf("verifySession returned error for a freshly signed token: %v", err)
+	}
+	if name != "alice" {
+		t.Errorf("name = %q, want %q", name, "alice")
+	}
+}
+
+func TestVerifySessionRejectsTamperedToken(t *testing.T) {
+	token := signSession("alice")
+
+	if _, err := verifySession(token + "x"); err == nil {
+		t.Fatal("expected an error for a tampered token, got nil")
+	}
+}
+
+func TestVerifySessionRejectsExpiredToken(t *testing.T) {
+	token := signSessionWithExpiry("alice", time.Now().Add(-time.Second))
+
+	if _, err := verifySession(token); err == nil {
+		t.Fatal("expected an error for an expired token, got nil")
+	}
+}
+
+func TestVerifySessionRejectsMalformedToken(t *testing.T) {
+	if _, err := verifySession("not-valid-base64!!"); err == nil {
+		t.Fatal("expected an error for a malformed token, got nil")
+	}
+}
+
+func TestHashPasswordCheckPasswordRoundTrip(t *testing.T) {
+	hash, err := hashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("hashPassword returned error: %v", err)
+	}
+	if !checkPassword("correct horse battery staple", hash) {
+		t.Error("checkPassword rejected the password it was hashed from")
+	}
+	if checkPassword("wrong password", hash) {
+		t.Error("checkPassword accepted an incorrect password")
+	}
+}
+
+func TestHashPasswordSaltsEachCall(t *testing.T) {
+	hash1, err := hashPassword("same-password")
+	if err != nil {
+		t.Fatalf("hashPassword returned error: %v", err)
+	}
+	hash2, err := hashPassword("same-password")
+	if err != nil {
+		t.Fatalf("hashPassword returned error: %v", err)
+	}
+	if hash1 == hash2 {
+		t.Error("hashing the same password twice produced identical hashes, want distinct salts")
+	}
+}
+
+// TestBeginTwoFactorChallengeConfirmHandlerFlow drives the full 2FA path:
+// beginTwoFactorChallenge records a pending withdrawal and a one-time code,
+// and confirmHandler only completes it when given the right challenge ID
+// and code.
+func TestBeginTwoFactorChallengeConfirmHandlerFlow(t *testing.T) {
+	repo = NewInMemoryRepository()
+	repo.Save("alice", newTestAccount("alice", 1000))
+
+	op := beginOperation(OpWithdraw, "alice", 500)
+	challengeID, err := beginTwoFactorChallenge("withdraw", "alice", 500, op.ID)
+	if err != nil {
+		t.Fatalf("beginTwoFactorChallenge returned error: %v", err)
+	}
+
+	pendingOpsMutex.Lock()
+	pending := pendingOps[challengeID]
+	pendingOpsMutex.Unlock()
+
+	confirmRequest(t, challengeID, pending.Code)
+	if got := op.snapshot().State; got != OpConfirmed {
+		t.Errorf("state after correct code = %s, want Confirmed", got)
+	}
+
+	alice, _ := repo.Get("alice")
+	if got, want := alice.CheckBalance(), 500.0; got != want {
+		t.Errorf("alice balance = %.2f, want %.2f", got, want)
+	}
+
+	// The challenge is deleted as soon as it's looked up, so confirming
+	// again with the same ID and code must not withdraw a second time.
+	confirmRequest(t, challengeID, pending.Code)
+	if got, want := alice.CheckBalance(), 500.0; got != want {
+		t.Errorf("alice balance after reusing the challenge = %.2f, want %.2f (challenge must be single-use)", got, want)
+	}
+}
+
+// TestConfirmHandlerRejectsIncorrectCode checks that the wrong code rejects
+// the operation and consumes the challenge rather than completing the
+// withdrawal.
+func TestConfirmHandlerRejectsIncorrectCode(t *testing.T) {
+	repo = NewInMemoryRepository()
+	repo.Save("alice", newTestAccount("alice", 1000))
+
+	op := beginOperation(OpWithdraw, "alice", 500)
+	challengeID, err := beginTwoFactorChallenge("withdraw", "alice", 500, op.ID)
+	if err != nil {
+		t.Fatalf("beginTwoFactorChallenge returned error: %v", err)
+	}
+
+	confirmRequest(t, challengeID, "000000")
+
+	if got := op.snapshot().State; got != OpRejected {
+		t.Errorf("state = %s, want Rejected", got)
+	}
+	alice, _ := repo.Get("alice")
+	if got, want := alice.CheckBalance(), 1000.0; got != want {
+		t.Errorf("alice balance = %.2f, want %.2f (incorrect code must not withdraw)", got, want)
+	}
+}
+
+func TestConfirmHandlerRejectsExpiredChallenge(t *testing.T) {
+	repo = NewInMemoryRepository()
+	repo.Save("alice", newTestAccount("alice", 1000))
+
+	op := beginOperation(OpWithdraw, "alice", 500)
+	challengeID, err := beginTwoFactorChallenge("withdraw", "alice", 500, op.ID)
+	if err != nil {
+		t.Fatalf("beginTwoFactorChallenge returned error: %v", err)
+	}
+
+	pendingOpsMutex.Lock()
+	pending := pendingOps[challengeID]
+	pending.ExpiresAt = time.Now().Add(-time.Second)
+	pendingOps[challengeID] = pending
+	pendingOpsMutex.Unlock()
+
+	confirmRequest(t, challengeID, pending.Code)
+
+	alice, _ := repo.Get("alice")
+	if got, want := alice.CheckBalance(), 1000.0; got != want {
+		t.Errorf("alice balance = %.2f, want %.2f (expired challenge must not withdraw)", got, want)
+	}
+	if got := op.snapshot().State; got != OpRejected {
+		t.Errorf("state = %s, want Rejected", got)
+	}
+}
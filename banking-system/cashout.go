@@ -0,0 +1,267 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	cashoutFeeRate = 0.02 // 2% of the requested amount
+	quoteTTL       = 60 * time.Second
+)
+
+// cashoutSecret signs quote tokens, kept separate from sessionSecret so the
+// two token domains can't be confused with each other.
+var cashoutSecret = generateSecret()
+
+// CashoutAccount describes the source account and destination currency for
+// a cashout/FX conversion.
+type CashoutAccount struct {
+	SourceName string
+	Currency   string
+}
+
+// RateProvider looks up the exchange rate to convert USD into currency.
+type RateProvider interface {
+	Rate(currency string) (float64, error)
+}
+
+// StaticRateProvider serves fixed rates, useful for tests and local runs.
+type StaticRateProvider struct {
+	Rates map[string]float64
+}
+
+func (p *StaticRateProvider) Rate(currency string) (float64, error) {
+	rate, exists := p.Rates[currency]
+	if !exists {
+		return 0, fmt.Errorf("unsupported currency: %s", currency)
+	}
+	return rate, nil
+}
+
+// HTTPRateProvider fetches rates from an external FX service. It is a stub
+// until a real provider endpoint is configured.
+type HTTPRateProvider struct {
+	Endpoint string
+	Client   *http.Client
+}
+
+func (p *HTTPRateProvider) Rate(currency string) (float64, error) {
+	resp, err := p.Client.Get(p.Endpoint + "?currency=" + url.QueryEscape(currency))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return 0, fmt.Errorf("HTTPRateProvider: response parsing not yet implemented")
+}
+
+// rateProvider is the active FX rate source; swap for an HTTPRateProvider
+// once a real FX service is wired up.
+var rateProvider RateProvider = &StaticRateProvider{
+	Rates: map[string]float64{"USD": 1.0, "EUR": 0.92, "GBP": 0.78},
+}
+
+var (
+	usedQuoteNonces      = make(map[string]bool)
+	usedQuoteNoncesMutex sync.Mutex
+)
+
+// cashoutQuote is the data carried inside a signed quote token.
+type cashoutQuote struct {
+	Source   string
+	Currency string
+	Amount   float64
+	Fee      float64
+	Rate     float64
+	Output   float64
+	Nonce    string
+	Expiry   int64
+}
+
+func generateNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// signQuote encodes the quote fields and appends an HMAC signature, the
+// same pattern signSession uses for session cookies.
+func signQuote(q cashoutQuote) string {
+	payload := strings.Join([]string{
+		q.Source,
+		q.Currency,
+		strconv.FormatFloat(q.Amount, 'f', 2, 64),
+		strconv.FormatFloat(q.Fee, 'f', 2, 64),
+		strconv.FormatFloat(q.Rate, 'f', 6, 64),
+		strconv.FormatFloat(q.Output, 'f', 2, 64),
+		q.Nonce,
+		strconv.FormatInt(q.Expiry, 10),
+	}, "|")
+
+	mac := hmac.New(sha256.New, cashoutSecret)
+	mac.Write([]byte(payload))
+	signature := hex.EncodeToString(mac.Sum(nil))
+	return base64.URLEncoding.EncodeToString([]byte(payload + "|" + signature))
+}
+
+func verifyQuote(token string) (cashoutQuote, error) {
+	decoded, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return cashoutQuote{}, errors.New("invalid quote token")
+	}
+	parts := strings.Split(string(decoded), "|")
+	if len(parts) != 9 {
+		return cashoutQuote{}, errors.New("malformed quote token")
+	}
+
+	payload := strings.Join(parts[:8], "|")
+	signature := parts[8]
+	mac := hmac.New(sha256.New, cashoutSecret)
+	mac.Write([]byte(payload))
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return cashoutQuote{}, errors.New("invalid quote signature")
+	}
+
+	amount, _ := strconv.ParseFloat(parts[2], 64)
+	fee, _ := strconv.ParseFloat(parts[3], 64)
+	rate, _ := strconv.ParseFloat(parts[4], 64)
+	output, _ := strconv.ParseFloat(parts[5], 64)
+	expiry, err := strconv.ParseInt(parts[7], 10, 64)
+	if err != nil {
+		return cashoutQuote{}, errors.New("malformed quote expiry")
+	}
+
+	q := cashoutQuote{
+		Source:   parts[0],
+		Currency: parts[1],
+		Amount:   amount,
+		Fee:      fee,
+		Rate:     rate,
+		Output:   output,
+		Nonce:    parts[6],
+		Expiry:   expiry,
+	}
+	if time.Now().Unix() > q.Expiry {
+		return cashoutQuote{}, errors.New("quote expired")
+	}
+	return q, nil
+}
+
+// Cashout Estimate Handler
+func cashoutEstimateHandler(w http.ResponseWriter, r *http.Request) {
+	account := CashoutAccount{
+		SourceName: accountNameFromRequest(r),
+		Currency:   r.FormValue("currency"),
+	}
+	amount, _ := strconv.ParseFloat(r.FormValue("amount"), 64)
+
+	if amount <= 0 {
+		sendResponse(w, "Invalid cashout amount")
+		return
+	}
+
+	rate, err := rateProvider.Rate(account.Currency)
+	if err != nil {
+		sendResponse(w, err.Error())
+		return
+	}
+
+	nonce, err := generateNonce()
+	if err != nil {
+		http.Error(w, "Could not create quote", http.StatusInternalServerError)
+		return
+	}
+
+	fee := amount * cashoutFeeRate
+	output := (amount - fee) * rate
+	quote := cashoutQuote{
+		Source:   account.SourceName,
+		Currency: account.Currency,
+		Amount:   amount,
+		Fee:      fee,
+		Rate:     rate,
+		Output:   output,
+		Nonce:    nonce,
+		Expiry:   time.Now().Add(quoteTTL).Unix(),
+	}
+
+	sendResponse(w, fmt.Sprintf(
+		"Quote: $%.2f -> %.2f %s (fee $%.2f, rate %.4f). Token: %s",
+		amount, output, account.Currency, fee, rate, signQuote(quote),
+	))
+}
+
+// Cashout Confirm Handler
+func cashoutConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.FormValue("token")
+
+	quote, err := verifyQuote(token)
+	if err != nil {
+		sendResponse(w, err.Error())
+		return
+	}
+
+	if quote.Source != accountNameFromRequest(r) {
+		sendResponse(w, "Quote does not belong to the authenticated account")
+		return
+	}
+
+	usedQuoteNoncesMutex.Lock()
+	if usedQuoteNonces[quote.Nonce] {
+		usedQuoteNoncesMutex.Unlock()
+		sendResponse(w, "Quote already redeemed")
+		return
+	}
+	usedQuoteNonces[quote.Nonce] = true
+	usedQuoteNoncesMutex.Unlock()
+
+	op := beginOperation(OpCashout, quote.Source, quote.Amount)
+
+	if _, exists := repo.Get(quote.Source); !exists {
+		transitionOperation(op, OpRejected, fmt.Errorf("account not found"))
+		sendResponse(w, "Account not found")
+		return
+	}
+
+	// Mutate holds the repository lock across the whole read-modify-write so
+	// a cashout can't race a concurrent withdrawal/deposit on the same
+	// account.
+	var newBalance float64
+	err = repo.Mutate(quote.Source, func(acc Account) error {
+		if err := acc.Withdraw(quote.Amount); err != nil {
+			return err
+		}
+		newBalance = acc.CheckBalance()
+		return nil
+	})
+	if err != nil {
+		transitionOperation(op, OpRejected, err)
+		sendResponse(w, err.Error())
+		return
+	}
+
+	repo.AppendHistory(quote.Source, Transaction{
+		ID:           newTransactionID(),
+		Time:         time.Now(),
+		Kind:         KindCashout,
+		Amount:       quote.Amount,
+		BalanceAfter: newBalance,
+		Note:         fmt.Sprintf("%.2f %s (fee $%.2f)", quote.Output, quote.Currency, quote.Fee),
+	})
+	transitionOperation(op, OpConfirmed, nil)
+	sendResponse(w, fmt.Sprintf("Cashout confirmed! Received %.2f %s. New Balance: $%.2f (operation %s)", quote.Output, quote.Currency, newBalance, op.ID))
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSignQuoteVerifyQuoteRoundTrip(t *testing.T) {
+	q := cashoutQuote{
+		Source:   "alice",
+		Currency: "EUR",
+		Amount:   100,
+		Fee:      2,
+		Rate:     0.92,
+		Output:   90.16,
+		Nonce:    "test-nonce",
+		Expiry:   time.Now().Add(quoteTTL).Unix(),
+	}
+
+	token := signQuote(q)
+	got, err := verifyQuote(token)
+	if err != nil {
+		t.Fatalf("verifyQuote returned error for a freshly signed quote: %v", err)
+	}
+	if got.Source != q.Source || got.Currency != q.Currency || got.Nonce != q.Nonce {
+		t.Errorf("verifyQuote = %+v, want fields matching %+v", got, q)
+	}
+}
+
+func TestVerifyQuoteRejectsTamperedToken(t *testing.T) {
+	q := cashoutQuote{
+		Source: "alice", Currency: "EUR", Amount: 100, Fee: 2, Rate: 0.92,
+		Output: 90.16, Nonce: "test-nonce", Expiry: time.Now().Add(quoteTTL).Unix(),
+	}
+	token := signQuote(q)
+
+	tampered, err := verifyQuote(token + "x")
+	if err == nil {
+		t.Fatalf("expected an error for a tampered token, got quote %+v", tampered)
+	}
+}
+
+func TestVerifyQuoteRejectsExpiredToken(t *testing.T) {
+	q := cashoutQuote{
+		Source: "alice", Currency: "EUR", Amount: 100, Fee: 2, Rate: 0.92,
+		Output: 90.16, Nonce: "test-nonce", Expiry: time.Now().Add(-time.Second).Unix(),
+	}
+	token := signQuote(q)
+
+	if _, err := verifyQuote(token); err == nil {
+		t.Fatal("expected an error for an expired quote, got nil")
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// TransactionKind enumerates the kinds of ledger entries an account can
+// have in its history.
+type TransactionKind string
+
+const (
+	KindDeposit     TransactionKind = "Deposit"
+	KindWithdraw    TransactionKind = "Withdraw"
+	KindTransferIn  TransactionKind = "TransferIn"
+	KindTransferOut TransactionKind = "TransferOut"
+	KindCashout     TransactionKind = "Cashout"
+)
+
+// Transaction is a single structured ledger entry, replacing the old
+// free-text history strings.
+type Transaction struct {
+	ID           string          `json:"id"`
+	Time         time.Time       `json:"time"`
+	Kind         TransactionKind `json:"kind"`
+	Amount       float64         `json:"amount"`
+	BalanceAfter float64         `json:"balanceAfter"`
+	Counterparty string          `json:"counterparty,omitempty"`
+	Note         string          `json:"note,omitempty"`
+}
+
+// newTransactionID returns a random identifier for a Transaction, falling
+// back to a time-based one on the vanishingly rare chance crypto/rand
+// fails.
+func newTransactionID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Cursor is an opaque pagination token; an empty Cursor means there are no
+// more pages.
+type Cursor string
+
+// HistoryFilter narrows down GetHistory results. The zero value matches
+// everything.
+type HistoryFilter struct {
+	From   time.Time
+	To     time.Time
+	Kind   TransactionKind
+	Limit  int
+	Cursor Cursor
+}
+
+// filterHistory applies a HistoryFilter over a chronological transaction
+// slice and returns the matching page plus a cursor for the next one.
+func filterHistory(history []Transaction, filter HistoryFilter) ([]Transaction, Cursor) {
+	start := 0
+	if filter.Cursor != "" {
+		if n, err := strconv.Atoi(string(filter.Cursor)); err == nil {
+			start = n
+		}
+	}
+
+	var matched []Transaction
+	for i := start; i < len(history); i++ {
+		tx := history[i]
+		if !filter.From.IsZero() && tx.Time.Before(filter.From) {
+			continue
+		}
+		if !filter.To.IsZero() && tx.Time.After(filter.To) {
+			continue
+		}
+		if filter.Kind != "" && tx.Kind != filter.Kind {
+			continue
+		}
+
+		matched = append(matched, tx)
+		if filter.Limit > 0 && len(matched) >= filter.Limit {
+			return matched, Cursor(strconv.Itoa(i + 1))
+		}
+	}
+	return matched, ""
+}
+
+// parseHistoryFilter builds a HistoryFilter from the /history query params.
+func parseHistoryFilter(r *http.Request) HistoryFilter {
+	query := r.URL.Query()
+	filter := HistoryFilter{Cursor: Cursor(query.Get("cursor"))}
+
+	if from := query.Get("from"); from != "" {
+		if t, err := time.Parse(time.RFC3339, from); err == nil {
+			filter.From = t
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		if t, err := time.Parse(time.RFC3339, to); err == nil {
+			filter.To = t
+		}
+	}
+	if kind := query.Get("kind"); kind != "" {
+		filter.Kind = TransactionKind(kind)
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if n, err := strconv.Atoi(limit); err == nil {
+			filter.Limit = n
+		}
+	}
+	return filter
+}
+
+// Transaction History Handler
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	name := accountNameFromRequest(r)
+
+	acc, exists := repo.Get(name)
+	if !exists {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	transactions, cursor := acc.GetHistory(parseHistoryFilter(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Transactions []Transaction `json:"transactions"`
+		Cursor       Cursor        `json:"cursor"`
+	}{transactions, cursor})
+}
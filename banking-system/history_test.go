@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func sampleHistory() []Transaction {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	return []Transaction{
+		{ID: "1", Time: base, Kind: KindDeposit, Amount: 100},
+		{ID: "2", Time: base.Add(time.Hour), Kind: KindWithdraw, Amount: 20},
+		{ID: "3", Time: base.Add(2 * time.Hour), Kind: KindTransferOut, Amount: 30},
+		{ID: "4", Time: base.Add(3 * time.Hour), Kind: KindTransferIn, Amount: 30},
+		{ID: "5", Time: base.Add(4 * time.Hour), Kind: KindDeposit, Amount: 50},
+	}
+}
+
+func TestFilterHistoryByKind(t *testing.T) {
+	matched, cursor := filterHistory(sampleHistory(), HistoryFilter{Kind: KindDeposit})
+	if len(matched) != 2 {
+		t.Fatalf("got %d deposits, want 2", len(matched))
+	}
+	if matched[0].ID != "1" || matched[1].ID != "5" {
+		t.Errorf("got IDs %s,%s, want 1,5", matched[0].ID, matched[1].ID)
+	}
+	if cursor != "" {
+		t.Errorf("cursor = %q, want empty when no Limit is set", cursor)
+	}
+}
+
+func TestFilterHistoryByDateRange(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	matched, _ := filterHistory(sampleHistory(), HistoryFilter{
+		From: base.Add(time.Hour),
+		To:   base.Add(3 * time.Hour),
+	})
+	if len(matched) != 3 {
+		t.Fatalf("got %d transactions, want 3", len(matched))
+	}
+	for _, tx := range matched {
+		if tx.ID == "1" || tx.ID == "5" {
+			t.Errorf("transaction %s is outside the requested range", tx.ID)
+		}
+	}
+}
+
+func TestFilterHistoryPagination(t *testing.T) {
+	history := sampleHistory()
+
+	page1, cursor := filterHistory(history, HistoryFilter{Limit: 2})
+	if len(page1) != 2 || page1[0].ID != "1" || page1[1].ID != "2" {
+		t.Fatalf("page1 = %+v, want transactions 1,2", page1)
+	}
+	if cursor == "" {
+		t.Fatal("expected a non-empty cursor when more results remain")
+	}
+
+	page2, cursor2 := filterHistory(history, HistoryFilter{Limit: 2, Cursor: cursor})
+	if len(page2) != 2 || page2[0].ID != "3" || page2[1].ID != "4" {
+		t.Fatalf("page2 = %+v, want transactions 3,4", page2)
+	}
+
+	page3, cursor3 := filterHistory(history, HistoryFilter{Limit: 2, Cursor: cursor2})
+	if len(page3) != 1 || page3[0].ID != "5" {
+		t.Fatalf("page3 = %+v, want transaction 5", page3)
+	}
+	if cursor3 != "" {
+		t.Errorf("cursor3 = %q, want empty once the history is exhausted", cursor3)
+	}
+}
@@ -2,9 +2,11 @@ package main
 
 import (
 	"fmt"
+	"log"
 	"net/http"
+	"os"
 	"strconv"
-	"sync"
+	"time"
 )
 
 // Account Interface
@@ -12,15 +14,18 @@ type Account interface {
 	Deposit(amount float64) error
 	Withdraw(amount float64) error
 	CheckBalance() float64
-	GetHistory() []string
+	GetHistory(filter HistoryFilter) ([]Transaction, Cursor)
+	Transfer(to Account, amount float64, idempotencyKey string) error
 }
 
 // Savings Account Struct
 type SavingsAccount struct {
-	Name    string
-	Balance *float64
-	Limit   float64
-	History []string
+	Name      string
+	Balance   *float64
+	Limit     float64
+	History   []Transaction
+	CreatedAt time.Time
+	Frozen    bool
 }
 
 // Current Account Struct
@@ -28,36 +33,39 @@ type CurrentAccount struct {
 	Name      string
 	Balance   *float64
 	Overdraft float64
-	History   []string
+	History   []Transaction
+	CreatedAt time.Time
+	Frozen    bool
 }
 
-// Global Account Storage
-var (
-	accounts = make(map[string]Account)
-	mutex    sync.Mutex
-)
-
 // Deposit Money
 func (a *SavingsAccount) Deposit(amount float64) error {
+	if a.Frozen {
+		return fmt.Errorf("Account is frozen")
+	}
 	if amount <= 0 {
 		return fmt.Errorf("Invalid deposit amount")
 	}
 	*a.Balance += amount
-	a.History = append(a.History, fmt.Sprintf("Deposited: $%.2f", amount))
 	return nil
 }
 
 func (a *CurrentAccount) Deposit(amount float64) error {
+	if a.Frozen {
+		return fmt.Errorf("Account is frozen")
+	}
 	if amount <= 0 {
 		return fmt.Errorf("Invalid deposit amount")
 	}
 	*a.Balance += amount
-	a.History = append(a.History, fmt.Sprintf("Deposited: $%.2f", amount))
 	return nil
 }
 
 // Withdraw Money
 func (a *SavingsAccount) Withdraw(amount float64) error {
+	if a.Frozen {
+		return fmt.Errorf("Account is frozen")
+	}
 	if amount <= 0 {
 		return fmt.Errorf("Invalid withdrawal amount")
 	}
@@ -69,18 +77,20 @@ func (a *SavingsAccount) Withdraw(amount float64) error {
 	}
 
 	*a.Balance -= amount
-	transaction := fmt.Sprintf("Withdrew: $%.2f, Final Balance: $%.2f", amount, *a.Balance)
-	a.History = append(a.History, transaction)
-
 	return nil
 }
 
 func (a *CurrentAccount) Withdraw(amount float64) error {
+	if a.Frozen {
+		return fmt.Errorf("Account is frozen")
+	}
+	if amount <= 0 {
+		return fmt.Errorf("Invalid withdrawal amount")
+	}
 	if *a.Balance-amount < -a.Overdraft {
 		return fmt.Errorf("Overdraft limit exceeded!")
 	}
 	*a.Balance -= amount
-	a.History = append(a.History, fmt.Sprintf("Withdrew: $%.2f", amount))
 	return nil
 }
 
@@ -89,8 +99,12 @@ func (a *SavingsAccount) CheckBalance() float64 { return *a.Balance }
 func (a *CurrentAccount) CheckBalance() float64 { return *a.Balance }
 
 // Get Transaction History
-func (a *SavingsAccount) GetHistory() []string { return a.History }
-func (a *CurrentAccount) GetHistory() []string { return a.History }
+func (a *SavingsAccount) GetHistory(filter HistoryFilter) ([]Transaction, Cursor) {
+	return filterHistory(a.History, filter)
+}
+func (a *CurrentAccount) GetHistory(filter HistoryFilter) ([]Transaction, Cursor) {
+	return filterHistory(a.History, filter)
+}
 
 // Create Account Handler
 func createAccount(w http.ResponseWriter, r *http.Request) {
@@ -98,92 +112,125 @@ func createAccount(w http.ResponseWriter, r *http.Request) {
 	balance, _ := strconv.ParseFloat(r.FormValue("balance"), 64)
 	accountType := r.FormValue("accountType")
 
-	mutex.Lock()
-	defer mutex.Unlock()
-
-	if _, exists := accounts[name]; exists {
+	if _, exists := repo.Get(name); exists {
 		sendResponse(w, "Account already exists")
 		return
 	}
 
 	initialBalance := balance
+	createdAt := time.Now()
+	var acc Account
 	if accountType == "Savings" {
-		accounts[name] = &SavingsAccount{Name: name, Balance: &initialBalance, Limit: 500.00}
+		acc = &SavingsAccount{Name: name, Balance: &initialBalance, Limit: 500.00, CreatedAt: createdAt}
 	} else {
-		accounts[name] = &CurrentAccount{Name: name, Balance: &initialBalance, Overdraft: 1000.00}
+		acc = &CurrentAccount{Name: name, Balance: &initialBalance, Overdraft: 1000.00, CreatedAt: createdAt}
 	}
 
+	if err := repo.Save(name, acc); err != nil {
+		http.Error(w, "Could not create account", http.StatusInternalServerError)
+		return
+	}
 	sendResponse(w, "Account created successfully")
 }
 
 // Deposit Money Handler
 func depositMoney(w http.ResponseWriter, r *http.Request) {
-	name := r.FormValue("name")
+	name := accountNameFromRequest(r)
 	amount, _ := strconv.ParseFloat(r.FormValue("amount"), 64)
 
-	mutex.Lock()
-	defer mutex.Unlock()
+	op := beginOperation(OpDeposit, name, amount)
 
-	if acc, exists := accounts[name]; exists {
-		err := acc.Deposit(amount)
-		if err != nil {
-			sendResponse(w, err.Error())
-		} else {
-			sendResponse(w, fmt.Sprintf("Deposit successful! New Balance: $%.2f", acc.CheckBalance()))
-		}
-	} else {
+	if _, exists := repo.Get(name); !exists {
+		transitionOperation(op, OpRejected, fmt.Errorf("account not found"))
 		sendResponse(w, "Account not found")
+		return
 	}
+
+	// Mutate holds the repository lock across the whole read-modify-write so
+	// two concurrent deposits on the same account can't race each other.
+	var newBalance float64
+	err := repo.Mutate(name, func(acc Account) error {
+		if err := acc.Deposit(amount); err != nil {
+			return err
+		}
+		newBalance = acc.CheckBalance()
+		return nil
+	})
+	if err != nil {
+		transitionOperation(op, OpRejected, err)
+		sendResponse(w, err.Error())
+		return
+	}
+	repo.AppendHistory(name, Transaction{
+		ID:           newTransactionID(),
+		Time:         time.Now(),
+		Kind:         KindDeposit,
+		Amount:       amount,
+		BalanceAfter: newBalance,
+	})
+	transitionOperation(op, OpConfirmed, nil)
+	sendResponse(w, fmt.Sprintf("Deposit successful! New Balance: $%.2f (operation %s)", newBalance, op.ID))
 }
 
-// Withdraw Money Handler
+// Withdraw Money Handler. Withdrawals above twoFactorThreshold are held as a
+// pending operation until the caller confirms a one-time code via /confirm.
 func withdrawMoney(w http.ResponseWriter, r *http.Request) {
-	name := r.FormValue("name")
+	name := accountNameFromRequest(r)
 	amount, _ := strconv.ParseFloat(r.FormValue("amount"), 64)
 
-	mutex.Lock()
-	defer mutex.Unlock()
-
-	if acc, exists := accounts[name]; exists {
-		err := acc.Withdraw(amount)
+	if amount > twoFactorThreshold {
+		op := beginOperation(OpWithdraw, name, amount)
+		challengeID, err := beginTwoFactorChallenge("withdraw", name, amount, op.ID)
 		if err != nil {
-			sendResponse(w, err.Error())
-		} else {
-			sendResponse(w, fmt.Sprintf("Withdrawal successful! New Balance: $%.2f", acc.CheckBalance()))
+			transitionOperation(op, OpRejected, err)
+			http.Error(w, "Could not start verification", http.StatusInternalServerError)
+			return
 		}
-	} else {
-		sendResponse(w, "Account not found")
+		sendResponse(w, fmt.Sprintf("Verification required. Challenge ID: %s, Operation ID: %s", challengeID, op.ID))
+		return
 	}
-}
-
-// Check Balance Handler
-func checkBalance(w http.ResponseWriter, r *http.Request) {
-	name := r.URL.Query().Get("name")
 
-	mutex.Lock()
-	defer mutex.Unlock()
+	op := beginOperation(OpWithdraw, name, amount)
 
-	if acc, exists := accounts[name]; exists {
-		sendResponse(w, fmt.Sprintf("Balance: $%.2f", acc.CheckBalance()))
-	} else {
+	if _, exists := repo.Get(name); !exists {
+		transitionOperation(op, OpRejected, fmt.Errorf("account not found"))
 		sendResponse(w, "Account not found")
+		return
 	}
-}
 
-// Transaction History Handler
-func transactionHistory(w http.ResponseWriter, r *http.Request) {
-	name := r.URL.Query().Get("name")
+	// Mutate holds the repository lock across the whole read-modify-write so
+	// two concurrent withdrawals on the same account can't both see the
+	// pre-withdrawal balance and double-spend it.
+	var newBalance float64
+	err := repo.Mutate(name, func(acc Account) error {
+		if err := acc.Withdraw(amount); err != nil {
+			return err
+		}
+		newBalance = acc.CheckBalance()
+		return nil
+	})
+	if err != nil {
+		transitionOperation(op, OpRejected, err)
+		sendResponse(w, err.Error())
+		return
+	}
+	repo.AppendHistory(name, Transaction{
+		ID:           newTransactionID(),
+		Time:         time.Now(),
+		Kind:         KindWithdraw,
+		Amount:       amount,
+		BalanceAfter: newBalance,
+	})
+	transitionOperation(op, OpConfirmed, nil)
+	sendResponse(w, fmt.Sprintf("Withdrawal successful! New Balance: $%.2f (operation %s)", newBalance, op.ID))
+}
 
-	mutex.Lock()
-	defer mutex.Unlock()
+// Check Balance Handler
+func checkBalance(w http.ResponseWriter, r *http.Request) {
+	name := accountNameFromRequest(r)
 
-	if acc, exists := accounts[name]; exists {
-		history := acc.GetHistory()
-		response := ""
-		for _, transaction := range history {
-			response += transaction + "<br>"
-		}
-		sendResponse(w, response)
+	if acc, exists := repo.Get(name); exists {
+		sendResponse(w, fmt.Sprintf("Balance: $%.2f", acc.CheckBalance()))
 	} else {
 		sendResponse(w, "Account not found")
 	}
@@ -274,6 +321,19 @@ func homeHandler(w http.ResponseWriter, r *http.Request) {
 				border-radius: 10px;
 				box-shadow: 0 4px 8px rgba(0,0,0,0.2);
 				backdrop-filter: blur(5px);
+				max-width: 90vw;
+				max-height: 80vh;
+				overflow: auto;
+			}
+			#resultModal table {
+				border-collapse: collapse;
+				width: 100%;
+			}
+			#resultModal th, #resultModal td {
+				border: 1px solid #ccc;
+				padding: 6px 10px;
+				font-size: 0.9em;
+				text-align: left;
 			}
 			@media (max-width: 768px) {
 				.container { width: 100%; }
@@ -301,12 +361,35 @@ func homeHandler(w http.ResponseWriter, r *http.Request) {
 				</div>
 			</div>
 
+			<!-- Register Login -->
+			<div class="card">
+				<div class="card-header" onclick="toggleCard(this)">🔐 Register Login ⬇️</div>
+				<div class="card-content">
+					<form onsubmit="return handleSubmit(event, '/register')">
+						<input type="text" name="name" placeholder="Account Name" required>
+						<input type="password" name="password" placeholder="Password" required>
+						<button type="submit">Register</button>
+					</form>
+				</div>
+			</div>
+
+			<!-- Login -->
+			<div class="card">
+				<div class="card-header" onclick="toggleCard(this)">🔑 Login ⬇️</div>
+				<div class="card-content">
+					<form onsubmit="return handleSubmit(event, '/login')">
+						<input type="text" name="name" placeholder="Account Name" required>
+						<input type="password" name="password" placeholder="Password" required>
+						<button type="submit">Login</button>
+					</form>
+				</div>
+			</div>
+
 			<!-- Deposit Money -->
 			<div class="card">
 				<div class="card-header" onclick="toggleCard(this)">💰 Deposit Money ⬇️</div>
 				<div class="card-content">
 					<form onsubmit="return handleSubmit(event, '/deposit')">
-						<input type="text" name="name" placeholder="Account Name" required>
 						<input type="number" name="amount" placeholder="Amount" required>
 						<button type="submit">Deposit</button>
 					</form>
@@ -318,19 +401,80 @@ func homeHandler(w http.ResponseWriter, r *http.Request) {
 				<div class="card-header" onclick="toggleCard(this)">🏧 Withdraw Money ⬇️</div>
 				<div class="card-content">
 					<form onsubmit="return handleSubmit(event, '/withdraw')">
-						<input type="text" name="name" placeholder="Account Name" required>
 						<input type="number" name="amount" placeholder="Amount" required>
 						<button type="submit">Withdraw</button>
 					</form>
 				</div>
 			</div>
 
+			<!-- Wire Transfer -->
+			<div class="card">
+				<div class="card-header" onclick="toggleCard(this)">↔️ Wire Transfer ⬇️</div>
+				<div class="card-content">
+					<form onsubmit="return handleSubmit(event, '/transfer')">
+						<input type="text" name="to" placeholder="Recipient Account Name" required>
+						<input type="number" name="amount" placeholder="Amount" required>
+						<input type="text" name="idempotencyKey" placeholder="Idempotency Key" required>
+						<button type="submit">Transfer</button>
+					</form>
+				</div>
+			</div>
+
+			<!-- Cashout Estimate -->
+			<div class="card">
+				<div class="card-header" onclick="toggleCard(this)">💱 Cashout Estimate ⬇️</div>
+				<div class="card-content">
+					<form onsubmit="return handleSubmit(event, '/cashout/estimate')">
+						<input type="number" name="amount" placeholder="Amount (USD)" required>
+						<input type="text" name="currency" placeholder="Currency (e.g. EUR)" required>
+						<button type="submit">Get Quote</button>
+					</form>
+				</div>
+			</div>
+
+			<!-- Cashout Confirm -->
+			<div class="card">
+				<div class="card-header" onclick="toggleCard(this)">✔️ Cashout Confirm ⬇️</div>
+				<div class="card-content">
+					<form onsubmit="return handleSubmit(event, '/cashout/confirm')">
+						<input type="text" name="token" placeholder="Quote Token" required>
+						<button type="submit">Confirm Cashout</button>
+					</form>
+				</div>
+			</div>
+
+			<!-- Confirm Verification -->
+			<div class="card">
+				<div class="card-header" onclick="toggleCard(this)">✅ Confirm Verification ⬇️</div>
+				<div class="card-content">
+					<form onsubmit="return handleSubmit(event, '/confirm')">
+						<input type="text" name="challengeId" placeholder="Challenge ID" required>
+						<input type="text" name="code" placeholder="One-Time Code" required>
+						<button type="submit">Confirm</button>
+					</form>
+				</div>
+			</div>
+
+			<!-- Operation Status -->
+			<div class="card">
+				<div class="card-header" onclick="toggleCard(this)">🔄 Operation Status ⬇️</div>
+				<div class="card-content">
+					<form onsubmit="return checkOperation(event)">
+						<input type="text" id="operationId" placeholder="Operation ID" required>
+						<button type="submit">Check Status</button>
+					</form>
+					<form onsubmit="return cancelOperation(event)">
+						<input type="text" id="cancelOperationId" placeholder="Operation ID" required>
+						<button type="submit">Cancel</button>
+					</form>
+				</div>
+			</div>
+
 			<!-- Check Balance -->
 			<div class="card">
 				<div class="card-header" onclick="toggleCard(this)">📊 Check Balance ⬇️</div>
 				<div class="card-content">
 					<form onsubmit="return checkBalance(event)">
-						<input type="text" id="balanceName" placeholder="Account Name" required>
 						<button type="submit">Check</button>
 					</form>
 				</div>
@@ -341,7 +485,6 @@ func homeHandler(w http.ResponseWriter, r *http.Request) {
 				<div class="card-header" onclick="toggleCard(this)">📜 Transaction History ⬇️</div>
 				<div class="card-content">
 					<form onsubmit="return fetchHistory(event)">
-						<input type="text" id="historyName" placeholder="Account Name" required>
 						<button type="submit">View</button>
 					</form>
 				</div>
@@ -363,20 +506,34 @@ func homeHandler(w http.ResponseWriter, r *http.Request) {
 
 			function checkBalance(event) {
 				event.preventDefault();
-				const name = document.getElementById('balanceName').value;
-				fetch('/balance?name=' + name)
+				fetch('/balance')
 					.then(res => res.text())
 					.then(data => showModal(data));
 			}
 
 			function fetchHistory(event) {
 				event.preventDefault();
-				const name = document.getElementById('historyName').value;
-				fetch('/history?name=' + name)
-					.then(res => res.json())  
+				fetch('/history')
+					.then(res => res.json())
 					.then(data => showHistoryModal(data));
 			}
 
+			function checkOperation(event) {
+				event.preventDefault();
+				const id = document.getElementById('operationId').value;
+				fetch('/op/' + id)
+					.then(res => res.json())
+					.then(data => showModal(JSON.stringify(data)));
+			}
+
+			function cancelOperation(event) {
+				event.preventDefault();
+				const id = document.getElementById('cancelOperationId').value;
+				fetch('/op/' + id + '/cancel', { method: 'POST' })
+					.then(res => res.text())
+					.then(data => showModal(data));
+			}
+
 			function showModal(message) {
 				const modal = document.getElementById('resultModal');
 				modal.innerHTML = '<h3>📢 Notification</h3><p>' + message + '</p>';
@@ -384,6 +541,20 @@ func homeHandler(w http.ResponseWriter, r *http.Request) {
 				setTimeout(() => modal.style.display = 'none', 5000);
 			}
 
+			function showHistoryModal(data) {
+				const modal = document.getElementById('resultModal');
+				const rows = (data.transactions || []).map(t =>
+					'<tr><td>' + new Date(t.time).toLocaleString() + '</td><td>' + t.kind + '</td>' +
+					'<td>$' + t.amount.toFixed(2) + '</td><td>$' + t.balanceAfter.toFixed(2) + '</td>' +
+					'<td>' + (t.counterparty || '') + '</td><td>' + (t.note || '') + '</td></tr>'
+				).join('');
+				modal.innerHTML = '<h3>📜 Transaction History</h3>' +
+					'<table><thead><tr><th>Time</th><th>Kind</th><th>Amount</th><th>Balance</th><th>Counterparty</th><th>Note</th></tr></thead>' +
+					'<tbody>' + (rows || '<tr><td colspan="6">No transactions</td></tr>') + '</tbody></table>';
+				modal.style.display = 'block';
+				setTimeout(() => modal.style.display = 'none', 8000);
+			}
+
 			function toggleCard(header) {
 				const card = header.parentElement;
 				card.classList.toggle('active');
@@ -394,13 +565,49 @@ func homeHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(tmpl))
 }
 
+// initRepository selects the storage backend from STORAGE_BACKEND
+// ("memory", the default, or "sql") so deployments can point at a real
+// database without a code change. The "sql" backend is Postgres-only -
+// see the SQLRepository doc comment in storage.go.
+func initRepository() AccountRepository {
+	switch os.Getenv("STORAGE_BACKEND") {
+	case "sql":
+		driver := os.Getenv("STORAGE_DRIVER")
+		if driver == "" {
+			driver = "postgres"
+		}
+		dsn := os.Getenv("STORAGE_DSN")
+		sqlRepo, err := NewSQLRepository(driver, dsn)
+		if err != nil {
+			log.Fatalf("could not initialize SQL storage backend: %v", err)
+		}
+		return sqlRepo
+	default:
+		return NewInMemoryRepository()
+	}
+}
+
 func main() {
-	http.HandleFunc("/", homeHandler)               // Home Page with UI
-	http.HandleFunc("/create", createAccount)       // Create Account
-	http.HandleFunc("/deposit", depositMoney)       // Deposit Money
-	http.HandleFunc("/withdraw", withdrawMoney)     // Withdraw Money
-	http.HandleFunc("/balance", checkBalance)       // Check Balance
-	http.HandleFunc("/history", transactionHistory) // Transaction History
+	repo = initRepository()
+	twoFactorThreshold = loadTwoFactorThreshold()
+
+	http.HandleFunc("/", homeHandler)                          // Home Page with UI
+	http.HandleFunc("/create", createAccount)                  // Create Account
+	http.HandleFunc("/register", registerHandler)              // Register Login
+	http.HandleFunc("/login", loginHandler)                    // Login
+	http.HandleFunc("/confirm", confirmHandler)                // Confirm 2FA Challenge
+	http.HandleFunc("/deposit", requireAuth(depositMoney))     // Deposit Money
+	http.HandleFunc("/withdraw", requireAuth(withdrawMoney))   // Withdraw Money
+	http.HandleFunc("/transfer", requireAuth(transferHandler))                 // Wire Transfer
+	http.HandleFunc("/cashout/estimate", requireAuth(cashoutEstimateHandler)) // Cashout Quote
+	http.HandleFunc("/cashout/confirm", requireAuth(cashoutConfirmHandler))   // Cashout Confirm
+	http.HandleFunc("/balance", requireAuth(checkBalance))     // Check Balance
+	http.HandleFunc("/history", requireAuth(historyHandler))   // Transaction History
+	http.HandleFunc("/op/stream", requireAuth(operationStreamHandler)) // Operation SSE Stream
+	http.HandleFunc("/op/", requireAuth(operationHandler))             // Operation Poll / Cancel
+	http.HandleFunc("/admin/accounts", requireAdmin(adminAccountsHandler))   // Admin Account List
+	http.HandleFunc("/admin/accounts/", requireAdmin(adminAccountsFreezeRouter)) // Admin Freeze / Unfreeze
+	http.HandleFunc("/admin/stats.csv", requireAdmin(adminStatsCSVHandler))  // Admin Daily Stats CSV
 	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
 	fmt.Println("Server is running on port 8080...")
 	fmt.Println("Server started at http://localhost:8080")
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OperationState is the state machine for a long-running request.
+type OperationState string
+
+const (
+	OpPending   OperationState = "Pending"
+	OpConfirmed OperationState = "Confirmed"
+	OpRejected  OperationState = "Rejected"
+	OpCancelled OperationState = "Cancelled"
+)
+
+// OperationKind identifies what an Operation represents.
+type OperationKind string
+
+const (
+	OpDeposit  OperationKind = "Deposit"
+	OpWithdraw OperationKind = "Withdraw"
+	OpTransfer OperationKind = "Transfer"
+	OpCashout  OperationKind = "Cashout"
+)
+
+// Operation is a first-class record of a deposit, withdrawal, transfer, or
+// cashout as it moves through Pending -> Confirmed | Rejected | Cancelled.
+// mu guards State/UpdatedAt/Error, which transitionOperation mutates while
+// operationHandler and broadcastOperation read them concurrently; it's a
+// pointer so copying an Operation by value (onto a subscriber channel, or
+// via snapshot) doesn't duplicate the lock.
+type Operation struct {
+	mu *sync.Mutex
+
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Kind      OperationKind  `json:"kind"`
+	Amount    float64        `json:"amount"`
+	State     OperationState `json:"state"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// snapshot returns a copy of op's fields taken under its lock, safe to
+// encode as JSON or hand to a subscriber channel without racing a
+// concurrent transitionOperation.
+func (op *Operation) snapshot() Operation {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	return *op
+}
+
+var operations sync.Map // map[string]*Operation
+
+var (
+	opSubscribers      = make(map[string][]chan Operation)
+	opSubscribersMutex sync.Mutex
+)
+
+func newOperationID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strings.ReplaceAll(time.Now().Format("20060102150405.000000000"), ".", "")
+	}
+	return hex.EncodeToString(b)
+}
+
+// beginOperation records a new Pending operation for the given account and
+// broadcasts it to any subscribers watching that account.
+func beginOperation(kind OperationKind, name string, amount float64) *Operation {
+	op := &Operation{
+		mu:        &sync.Mutex{},
+		ID:        newOperationID(),
+		Name:      name,
+		Kind:      kind,
+		Amount:    amount,
+		State:     OpPending,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	operations.Store(op.ID, op)
+	broadcastOperation(op)
+	return op
+}
+
+// transitionOperation moves an operation to a terminal (or cancelled)
+// state and broadcasts the new state to subscribers.
+func transitionOperation(op *Operation, state OperationState, opErr error) {
+	op.mu.Lock()
+	op.State = state
+	op.UpdatedAt = time.Now()
+	if opErr != nil {
+		op.Error = opErr.Error()
+	}
+	op.mu.Unlock()
+	broadcastOperation(op)
+}
+
+// transitionIfPending moves op out of Pending atomically, returning false
+// if another request (typically a concurrent /cancel) already moved it out
+// of Pending first. Callers use this to claim an operation before doing
+// something irreversible, so a race against cancellation fails safely
+// instead of both sides "succeeding".
+func (op *Operation) transitionIfPending(state OperationState, opErr error) bool {
+	op.mu.Lock()
+	if op.State != OpPending {
+		op.mu.Unlock()
+		return false
+	}
+	op.State = state
+	op.UpdatedAt = time.Now()
+	if opErr != nil {
+		op.Error = opErr.Error()
+	}
+	op.mu.Unlock()
+	broadcastOperation(op)
+	return true
+}
+
+func broadcastOperation(op *Operation) {
+	snap := op.snapshot()
+	opSubscribersMutex.Lock()
+	defer opSubscribersMutex.Unlock()
+	for _, ch := range opSubscribers[snap.Name] {
+		select {
+		case ch <- snap:
+		default:
+		}
+	}
+}
+
+// Operation Poll / Cancel Handler
+func operationHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/op/")
+	cancel := strings.HasSuffix(path, "/cancel")
+	id := strings.TrimSuffix(path, "/cancel")
+
+	value, exists := operations.Load(id)
+	if !exists {
+		http.Error(w, "Operation not found", http.StatusNotFound)
+		return
+	}
+	op := value.(*Operation)
+	if op.Name != accountNameFromRequest(r) {
+		http.Error(w, "Operation not found", http.StatusNotFound)
+		return
+	}
+
+	if cancel {
+		if !op.transitionIfPending(OpCancelled, nil) {
+			sendResponse(w, fmt.Sprintf("Operation is %s and can no longer be cancelled", op.snapshot().State))
+			return
+		}
+		sendResponse(w, "Operation cancelled")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(op.snapshot())
+}
+
+// Operation Stream Handler (Server-Sent Events)
+func operationStreamHandler(w http.ResponseWriter, r *http.Request) {
+	name := accountNameFromRequest(r)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	ch := make(chan Operation, 8)
+	opSubscribersMutex.Lock()
+	opSubscribers[name] = append(opSubscribers[name], ch)
+	opSubscribersMutex.Unlock()
+
+	defer func() {
+		opSubscribersMutex.Lock()
+		subs := opSubscribers[name]
+		for i, sub := range subs {
+			if sub == ch {
+				opSubscribers[name] = append(subs[:i], subs[i+1:]...)
+				break
+			}
+		}
+		opSubscribersMutex.Unlock()
+		close(ch)
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	for {
+		select {
+		case op, open := <-ch:
+			if !open {
+				return
+			}
+			payload, _ := json.Marshal(op)
+			fmt.Fprintf(w, "data: %s\n\n", payload)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// TestOperationTransitionIfPendingIsCompareAndSet checks that a cancel and a
+// confirm racing the same Pending operation can't both win - exactly one of
+// transitionIfPending's callers should succeed.
+func TestOperationTransitionIfPendingIsCompareAndSet(t *testing.T) {
+	op := beginOperation(OpWithdraw, "alice", 5000)
+
+	const attempts = 50
+	var wg sync.WaitGroup
+	results := make([]bool, attempts)
+	wg.Add(attempts)
+	for i := 0; i < attempts; i++ {
+		i := i
+		go func() {
+			defer wg.Done()
+			if i%2 == 0 {
+				results[i] = op.transitionIfPending(OpCancelled, nil)
+			} else {
+				results[i] = op.transitionIfPending(OpConfirmed, nil)
+			}
+		}()
+	}
+	wg.Wait()
+
+	wins := 0
+	for _, won := range results {
+		if won {
+			wins++
+		}
+	}
+	if wins != 1 {
+		t.Errorf("got %d winning transitions, want exactly 1", wins)
+	}
+
+	state := op.snapshot().State
+	if state != OpCancelled && state != OpConfirmed {
+		t.Errorf("final state = %s, want Cancelled or Confirmed", state)
+	}
+}
+
+func TestOperationTransitionIfPendingFailsOnceTerminal(t *testing.T) {
+	op := beginOperation(OpWithdraw, "alice", 100)
+
+	if !op.transitionIfPending(OpCancelled, nil) {
+		t.Fatal("first transition from Pending should succeed")
+	}
+	if op.transitionIfPending(OpConfirmed, nil) {
+		t.Fatal("transition from a terminal state should fail")
+	}
+	if got := op.snapshot().State; got != OpCancelled {
+		t.Errorf("state = %s, want Cancelled", got)
+	}
+}
+
+// TestOperationHandlerRejectsOtherAccountsOperation checks that a caller
+// authenticated as one account can't poll or cancel an operation that
+// belongs to a different account, even knowing its ID.
+func TestOperationHandlerRejectsOtherAccountsOperation(t *testing.T) {
+	op := beginOperation(OpWithdraw, "alice", 100)
+
+	asBob := func(path string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, path, nil)
+		return r.WithContext(context.WithValue(r.Context(), accountNameKey, "bob"))
+	}
+
+	w := httptest.NewRecorder()
+	operationHandler(w, asBob("/op/"+op.ID))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("poll as bob: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	w = httptest.NewRecorder()
+	operationHandler(w, asBob("/op/"+op.ID+"/cancel"))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("cancel as bob: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if got := op.snapshot().State; got != OpPending {
+		t.Errorf("state = %s, want Pending (bob's cancel must not apply)", got)
+	}
+}
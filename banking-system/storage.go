@@ -0,0 +1,399 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AccountRepository abstracts account persistence so the HTTP handlers
+// don't care whether accounts live in memory or in a real database.
+type AccountRepository interface {
+	Get(name string) (Account, bool)
+	Save(name string, acc Account) error
+	List() []Account
+	AppendHistory(name string, tx Transaction) error
+	TransferAtomic(from, to string, amount float64) error
+	Mutate(name string, fn func(Account) error) error
+	SetFrozen(name string, frozen bool) error
+}
+
+// repo is the active backend, selected in main() based on STORAGE_BACKEND.
+var repo AccountRepository
+
+// In-Memory Repository
+
+// InMemoryRepository keeps accounts in a process-local map. It preserves
+// the behavior of the original global `accounts` map and is the default
+// backend.
+type InMemoryRepository struct {
+	mu       sync.Mutex
+	accounts map[string]Account
+}
+
+func NewInMemoryRepository() *InMemoryRepository {
+	return &InMemoryRepository{accounts: make(map[string]Account)}
+}
+
+func (r *InMemoryRepository) Get(name string) (Account, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	acc, exists := r.accounts[name]
+	return acc, exists
+}
+
+func (r *InMemoryRepository) Save(name string, acc Account) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.accounts[name] = acc
+	return nil
+}
+
+func (r *InMemoryRepository) List() []Account {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]Account, 0, len(r.accounts))
+	for _, acc := range r.accounts {
+		list = append(list, acc)
+	}
+	return list
+}
+
+func (r *InMemoryRepository) AppendHistory(name string, tx Transaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	acc, exists := r.accounts[name]
+	if !exists {
+		return fmt.Errorf("account not found: %s", name)
+	}
+	switch a := acc.(type) {
+	case *SavingsAccount:
+		a.History = append(a.History, tx)
+	case *CurrentAccount:
+		a.History = append(a.History, tx)
+	}
+	return nil
+}
+
+// Mutate holds the repository lock across the read-modify-write so two
+// concurrent deposits/withdrawals on the same account can't race each
+// other the way a separate Get+Save would.
+func (r *InMemoryRepository) Mutate(name string, fn func(Account) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	acc, exists := r.accounts[name]
+	if !exists {
+		return fmt.Errorf("account not found: %s", name)
+	}
+	return fn(acc)
+}
+
+// SetFrozen flips the Frozen flag under the repository lock, the same lock
+// Mutate holds, so it can't race a concurrent deposit/withdraw/transfer and
+// revert the balance change they made.
+func (r *InMemoryRepository) SetFrozen(name string, frozen bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	acc, exists := r.accounts[name]
+	if !exists {
+		return fmt.Errorf("account not found: %s", name)
+	}
+	setFrozen(acc, frozen)
+	return nil
+}
+
+// TransferAtomic holds the repository lock for the whole debit+credit so
+// no other request can observe a half-applied transfer.
+func (r *InMemoryRepository) TransferAtomic(from, to string, amount float64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fromAcc, exists := r.accounts[from]
+	if !exists {
+		return fmt.Errorf("account not found: %s", from)
+	}
+	toAcc, exists := r.accounts[to]
+	if !exists {
+		return fmt.Errorf("account not found: %s", to)
+	}
+
+	if err := fromAcc.Withdraw(amount); err != nil {
+		return err
+	}
+	if err := toAcc.Deposit(amount); err != nil {
+		if rollbackErr := fromAcc.Deposit(amount); rollbackErr != nil {
+			return fmt.Errorf("%w (and rollback failed: %v)", err, rollbackErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// SQL-Backed Repository
+
+// SQLRepository persists accounts, transactions, and sessions through
+// database/sql. It targets Postgres specifically: the $1-style
+// placeholders, ON CONFLICT ... DO UPDATE, and the row-locking
+// SELECT ... FOR UPDATE in TransferAtomic/Mutate are Postgres syntax and
+// are not portable to SQLite. Callers must blank-import
+// github.com/lib/pq for driverName ("postgres") to resolve.
+type SQLRepository struct {
+	db *sql.DB
+}
+
+func NewSQLRepository(driverName, dsn string) (*SQLRepository, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	repo := &SQLRepository{db: db}
+	if err := repo.migrate(); err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+func (r *SQLRepository) migrate() error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS accounts (
+			name TEXT PRIMARY KEY,
+			kind TEXT NOT NULL,
+			balance DOUBLE PRECISION NOT NULL,
+			limit_or_overdraft DOUBLE PRECISION NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			frozen BOOLEAN NOT NULL DEFAULT FALSE
+		)`,
+		`CREATE TABLE IF NOT EXISTS transactions (
+			tx_id TEXT PRIMARY KEY,
+			account_name TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			amount DOUBLE PRECISION NOT NULL,
+			balance_after DOUBLE PRECISION NOT NULL,
+			counterparty TEXT NOT NULL DEFAULT '',
+			note TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS sessions (
+			token TEXT PRIMARY KEY,
+			account_name TEXT NOT NULL,
+			expires_at TIMESTAMP NOT NULL
+		)`,
+	}
+	for _, stmt := range statements {
+		if _, err := r.db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *SQLRepository) Get(name string) (Account, bool) {
+	var kind string
+	var balance, limitOrOverdraft float64
+	var createdAt time.Time
+	var frozen bool
+	row := r.db.QueryRow(`SELECT kind, balance, limit_or_overdraft, created_at, frozen FROM accounts WHERE name = $1`, name)
+	if err := row.Scan(&kind, &balance, &limitOrOverdraft, &createdAt, &frozen); err != nil {
+		return nil, false
+	}
+
+	history, err := r.history(name)
+	if err != nil {
+		return nil, false
+	}
+
+	b := balance
+	if kind == "Savings" {
+		return &SavingsAccount{Name: name, Balance: &b, Limit: limitOrOverdraft, History: history, CreatedAt: createdAt, Frozen: frozen}, true
+	}
+	return &CurrentAccount{Name: name, Balance: &b, Overdraft: limitOrOverdraft, History: history, CreatedAt: createdAt, Frozen: frozen}, true
+}
+
+func (r *SQLRepository) history(name string) ([]Transaction, error) {
+	rows, err := r.db.Query(`
+		SELECT tx_id, kind, amount, balance_after, counterparty, note, created_at
+		FROM transactions WHERE account_name = $1 ORDER BY created_at
+	`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []Transaction
+	for rows.Next() {
+		var tx Transaction
+		if err := rows.Scan(&tx.ID, &tx.Kind, &tx.Amount, &tx.BalanceAfter, &tx.Counterparty, &tx.Note, &tx.Time); err != nil {
+			return nil, err
+		}
+		history = append(history, tx)
+	}
+	return history, rows.Err()
+}
+
+func (r *SQLRepository) Save(name string, acc Account) error {
+	var kind string
+	var limitOrOverdraft float64
+	var createdAt time.Time
+	var frozen bool
+	switch a := acc.(type) {
+	case *SavingsAccount:
+		kind, limitOrOverdraft, createdAt, frozen = "Savings", a.Limit, a.CreatedAt, a.Frozen
+	case *CurrentAccount:
+		kind, limitOrOverdraft, createdAt, frozen = "Current", a.Overdraft, a.CreatedAt, a.Frozen
+	default:
+		return fmt.Errorf("unsupported account type for %s", name)
+	}
+
+	_, err := r.db.Exec(`
+		INSERT INTO accounts (name, kind, balance, limit_or_overdraft, created_at, frozen) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (name) DO UPDATE SET
+			balance = EXCLUDED.balance,
+			limit_or_overdraft = EXCLUDED.limit_or_overdraft,
+			frozen = EXCLUDED.frozen
+	`, name, kind, acc.CheckBalance(), limitOrOverdraft, createdAt, frozen)
+	return err
+}
+
+// SetFrozen updates only the frozen column, unlike Save which would
+// round-trip the whole row (including a possibly-stale balance) through a
+// read-modify-write and silently revert any deposit/withdraw/transfer that
+// committed in between.
+func (r *SQLRepository) SetFrozen(name string, frozen bool) error {
+	result, err := r.db.Exec(`UPDATE accounts SET frozen = $1 WHERE name = $2`, frozen, name)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("account not found: %s", name)
+	}
+	return nil
+}
+
+// loadForUpdate row-locks name within tx and builds the matching Account,
+// the same way Get does outside a transaction. Callers that need more than
+// one account (TransferAtomic) must lock every row in a canonical order -
+// see TransferAtomic for why.
+func (r *SQLRepository) loadForUpdate(tx *sql.Tx, name string) (Account, error) {
+	var kind string
+	var balance, limitOrOverdraft float64
+	var createdAt time.Time
+	var frozen bool
+	row := tx.QueryRow(`SELECT kind, balance, limit_or_overdraft, created_at, frozen FROM accounts WHERE name = $1 FOR UPDATE`, name)
+	if err := row.Scan(&kind, &balance, &limitOrOverdraft, &createdAt, &frozen); err != nil {
+		return nil, fmt.Errorf("account not found: %s", name)
+	}
+
+	b := balance
+	if kind == "Savings" {
+		return &SavingsAccount{Name: name, Balance: &b, Limit: limitOrOverdraft, CreatedAt: createdAt, Frozen: frozen}, nil
+	}
+	return &CurrentAccount{Name: name, Balance: &b, Overdraft: limitOrOverdraft, CreatedAt: createdAt, Frozen: frozen}, nil
+}
+
+// Mutate row-locks the account for the duration of a single database
+// transaction, so the read-modify-write fn performs mirrors the
+// in-memory backend's single-lock guarantee instead of racing across a
+// separate Get and Save.
+func (r *SQLRepository) Mutate(name string, fn func(Account) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	acc, err := r.loadForUpdate(tx, name)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(acc); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE accounts SET balance = $1 WHERE name = $2`, acc.CheckBalance(), name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *SQLRepository) List() []Account {
+	rows, err := r.db.Query(`SELECT name FROM accounts`)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var list []Account
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			continue
+		}
+		if acc, exists := r.Get(name); exists {
+			list = append(list, acc)
+		}
+	}
+	return list
+}
+
+func (r *SQLRepository) AppendHistory(name string, tx Transaction) error {
+	_, err := r.db.Exec(`
+		INSERT INTO transactions (tx_id, account_name, kind, amount, balance_after, counterparty, note, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, tx.ID, name, tx.Kind, tx.Amount, tx.BalanceAfter, tx.Counterparty, tx.Note, tx.Time)
+	return err
+}
+
+// TransferAtomic debits `from` and credits `to` inside a single database
+// transaction, row-locking both sides so concurrent requests can't
+// double-spend. It locks the two rows in name order rather than request
+// order, so a concurrent transfer running the other direction (to -> from)
+// locks them in the same order instead of deadlocking under FOR UPDATE.
+// The debit and credit go through Withdraw/Deposit, same as the in-memory
+// backend, so Frozen, SavingsAccount.Limit, and the overdraft bound are
+// enforced identically on both backends.
+func (r *SQLRepository) TransferAtomic(from, to string, amount float64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	first, second := from, to
+	if second < first {
+		first, second = second, first
+	}
+	locked := make(map[string]Account, 2)
+	for _, name := range []string{first, second} {
+		acc, err := r.loadForUpdate(tx, name)
+		if err != nil {
+			return err
+		}
+		locked[name] = acc
+	}
+	fromAcc, toAcc := locked[from], locked[to]
+
+	if err := fromAcc.Withdraw(amount); err != nil {
+		return err
+	}
+	if err := toAcc.Deposit(amount); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE accounts SET balance = $1 WHERE name = $2`, fromAcc.CheckBalance(), from); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE accounts SET balance = $1 WHERE name = $2`, toAcc.CheckBalance(), to); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
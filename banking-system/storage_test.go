@@ -0,0 +1,79 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestAccount(name string, balance float64) *CurrentAccount {
+	b := balance
+	return &CurrentAccount{Name: name, Balance: &b, Overdraft: 0, CreatedAt: time.Now()}
+}
+
+// TestInMemoryRepositoryMutateIsAtomic fires many concurrent withdrawals at
+// the same account through Mutate and checks the final balance accounts for
+// every one of them - a Get+mutate+Save race would let some withdrawals
+// double-spend the same starting balance.
+func TestInMemoryRepositoryMutateIsAtomic(t *testing.T) {
+	repo := NewInMemoryRepository()
+	repo.Save("alice", newTestAccount("alice", 1000))
+
+	const workers = 50
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			repo.Mutate("alice", func(acc Account) error {
+				return acc.Withdraw(10)
+			})
+		}()
+	}
+	wg.Wait()
+
+	acc, _ := repo.Get("alice")
+	if got, want := acc.CheckBalance(), 1000-float64(workers)*10; got != want {
+		t.Errorf("balance after %d concurrent withdrawals = %.2f, want %.2f", workers, got, want)
+	}
+}
+
+// TestInMemoryRepositoryTransferAtomicConcurrent checks that concurrent
+// transfers between two accounts always conserve the total balance, which
+// would fail if TransferAtomic didn't hold its lock across both legs.
+func TestInMemoryRepositoryTransferAtomicConcurrent(t *testing.T) {
+	repo := NewInMemoryRepository()
+	repo.Save("alice", newTestAccount("alice", 1000))
+	repo.Save("bob", newTestAccount("bob", 0))
+
+	const workers = 50
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			repo.TransferAtomic("alice", "bob", 10)
+		}()
+	}
+	wg.Wait()
+
+	alice, _ := repo.Get("alice")
+	bob, _ := repo.Get("bob")
+	if got, want := alice.CheckBalance(), 1000-float64(workers)*10; got != want {
+		t.Errorf("alice balance = %.2f, want %.2f", got, want)
+	}
+	if got, want := bob.CheckBalance(), float64(workers)*10; got != want {
+		t.Errorf("bob balance = %.2f, want %.2f", got, want)
+	}
+	if got, want := alice.CheckBalance()+bob.CheckBalance(), 1000.0; got != want {
+		t.Errorf("total balance = %.2f, want %.2f (money created or destroyed)", got, want)
+	}
+}
+
+func TestInMemoryRepositoryMutateUnknownAccount(t *testing.T) {
+	repo := NewInMemoryRepository()
+	err := repo.Mutate("nobody", func(acc Account) error { return nil })
+	if err == nil {
+		t.Fatal("expected an error for an unknown account, got nil")
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// transferOutcome is reserved for an idempotencyKey before the transfer
+// runs and filled in once it completes, so a concurrent request with the
+// same key waits for the in-flight transfer's result instead of racing it.
+type transferOutcome struct {
+	done chan struct{}
+	err  error
+}
+
+var (
+	transferOutcomes      = make(map[transferOutcomeKey]*transferOutcome)
+	transferOutcomesMutex sync.Mutex
+)
+
+// transferOutcomeKey scopes a reused idempotency key to the account it was
+// presented on, so two different callers can't collide by picking the same
+// free-text key - it only dedupes transfers from the same source account.
+type transferOutcomeKey struct {
+	fromName       string
+	idempotencyKey string
+}
+
+func accountName(acc Account) string {
+	switch a := acc.(type) {
+	case *SavingsAccount:
+		return a.Name
+	case *CurrentAccount:
+		return a.Name
+	default:
+		return ""
+	}
+}
+
+func (a *SavingsAccount) Transfer(to Account, amount float64, idempotencyKey string) error {
+	return transferBetween(accountName(a), accountName(to), amount, idempotencyKey)
+}
+
+func (a *CurrentAccount) Transfer(to Account, amount float64, idempotencyKey string) error {
+	return transferBetween(accountName(a), accountName(to), amount, idempotencyKey)
+}
+
+// transferBetween moves money through repo.TransferAtomic, which holds the
+// repository's own lock (or a row-locked DB transaction) across the whole
+// debit+credit, and records a paired history entry on both sides. A reused
+// idempotencyKey, scoped to fromName, reserves its outcome slot before the
+// transfer runs, under the same critical section that checks for a prior
+// result - a concurrent request with the same key from the same account
+// waits for that result instead of also executing the transfer.
+func transferBetween(fromName, toName string, amount float64, idempotencyKey string) error {
+	key := transferOutcomeKey{fromName: fromName, idempotencyKey: idempotencyKey}
+
+	transferOutcomesMutex.Lock()
+	if outcome, exists := transferOutcomes[key]; exists {
+		transferOutcomesMutex.Unlock()
+		<-outcome.done
+		return outcome.err
+	}
+	outcome := &transferOutcome{done: make(chan struct{})}
+	transferOutcomes[key] = outcome
+	transferOutcomesMutex.Unlock()
+
+	err := repo.TransferAtomic(fromName, toName, amount)
+	if err == nil {
+		if fromAcc, exists := repo.Get(fromName); exists {
+			repo.AppendHistory(fromName, Transaction{
+				ID:           newTransactionID(),
+				Time:         time.Now(),
+				Kind:         KindTransferOut,
+				Amount:       amount,
+				BalanceAfter: fromAcc.CheckBalance(),
+				Counterparty: toName,
+			})
+		}
+		if toAcc, exists := repo.Get(toName); exists {
+			repo.AppendHistory(toName, Transaction{
+				ID:           newTransactionID(),
+				Time:         time.Now(),
+				Kind:         KindTransferIn,
+				Amount:       amount,
+				BalanceAfter: toAcc.CheckBalance(),
+				Counterparty: fromName,
+			})
+		}
+	}
+
+	outcome.err = err
+	close(outcome.done)
+	return err
+}
+
+// Transfer Money Handler
+func transferHandler(w http.ResponseWriter, r *http.Request) {
+	fromName := accountNameFromRequest(r)
+	toName := r.FormValue("to")
+	amount, _ := strconv.ParseFloat(r.FormValue("amount"), 64)
+	idempotencyKey := r.FormValue("idempotencyKey")
+
+	if idempotencyKey == "" {
+		sendResponse(w, "Idempotency key is required")
+		return
+	}
+	if amount <= 0 {
+		sendResponse(w, "Invalid transfer amount")
+		return
+	}
+
+	fromAcc, exists := repo.Get(fromName)
+	if !exists {
+		sendResponse(w, "Account not found")
+		return
+	}
+	toAcc, exists := repo.Get(toName)
+	if !exists {
+		sendResponse(w, "Recipient account not found")
+		return
+	}
+
+	op := beginOperation(OpTransfer, fromName, amount)
+
+	if err := fromAcc.Transfer(toAcc, amount, idempotencyKey); err != nil {
+		transitionOperation(op, OpRejected, err)
+		sendResponse(w, err.Error())
+		return
+	}
+	transitionOperation(op, OpConfirmed, nil)
+
+	newBalance := fromAcc.CheckBalance()
+	if refreshed, exists := repo.Get(fromName); exists {
+		newBalance = refreshed.CheckBalance()
+	}
+	sendResponse(w, fmt.Sprintf("Transfer successful! New Balance: $%.2f (operation %s)", newBalance, op.ID))
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestTransferBetweenIdempotent checks that calling transferBetween many
+// times with the same idempotency key only moves money once, and that
+// every caller observes the same outcome.
+func TestTransferBetweenIdempotent(t *testing.T) {
+	repo = NewInMemoryRepository()
+	repo.Save("alice", newTestAccount("alice", 1000))
+	repo.Save("bob", newTestAccount("bob", 0))
+
+	const callers = 20
+	const key = "same-key"
+	var wg sync.WaitGroup
+	errs := make([]error, callers)
+	wg.Add(callers)
+	for i := 0; i < callers; i++ {
+		i := i
+		go func() {
+			defer wg.Done()
+			errs[i] = transferBetween("alice", "bob", 100, key)
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("call %d returned error %v, want nil", i, err)
+		}
+	}
+
+	alice, _ := repo.Get("alice")
+	bob, _ := repo.Get("bob")
+	if got, want := alice.CheckBalance(), 900.0; got != want {
+		t.Errorf("alice balance = %.2f, want %.2f (transfer ran more than once)", got, want)
+	}
+	if got, want := bob.CheckBalance(), 100.0; got != want {
+		t.Errorf("bob balance = %.2f, want %.2f (transfer ran more than once)", got, want)
+	}
+}
+
+// TestTransferBetweenDistinctKeysBothApply ensures the idempotency guard
+// only dedupes matching keys, not all transfers between the same accounts.
+func TestTransferBetweenDistinctKeysBothApply(t *testing.T) {
+	repo = NewInMemoryRepository()
+	repo.Save("alice", newTestAccount("alice", 1000))
+	repo.Save("bob", newTestAccount("bob", 0))
+
+	if err := transferBetween("alice", "bob", 100, "key-1"); err != nil {
+		t.Fatalf("first transfer: %v", err)
+	}
+	if err := transferBetween("alice", "bob", 100, "key-2"); err != nil {
+		t.Fatalf("second transfer: %v", err)
+	}
+
+	alice, _ := repo.Get("alice")
+	if got, want := alice.CheckBalance(), 800.0; got != want {
+		t.Errorf("alice balance = %.2f, want %.2f", got, want)
+	}
+}
+
+// TestTransferAtomicRejectsNonPositiveAmount ensures a negative or zero
+// amount is rejected before any balance is touched - a negative amount
+// would otherwise increase the source balance on the debit side
+// (Withdraw's overdraft check only guards the lower bound) while the
+// credit side's Deposit correctly rejects it.
+func TestTransferAtomicRejectsNonPositiveAmount(t *testing.T) {
+	repo = NewInMemoryRepository()
+	repo.Save("alice", newTestAccount("alice", 100))
+	repo.Save("bob", newTestAccount("bob", 0))
+
+	if err := repo.TransferAtomic("alice", "bob", -1000); err == nil {
+		t.Fatal("expected an error for a negative amount, got nil")
+	}
+
+	alice, _ := repo.Get("alice")
+	if got, want := alice.CheckBalance(), 100.0; got != want {
+		t.Errorf("alice balance = %.2f, want %.2f (negative transfer should not move money)", got, want)
+	}
+}
+
+// TestTransferBetweenSameKeyDifferentSourcesBothApply ensures the
+// idempotency guard is scoped per source account - two unrelated callers
+// who happen to reuse the same free-text key must not collide, with the
+// second transfer silently skipped and handed the first caller's result.
+func TestTransferBetweenSameKeyDifferentSourcesBothApply(t *testing.T) {
+	repo = NewInMemoryRepository()
+	repo.Save("alice", newTestAccount("alice", 1000))
+	repo.Save("bob", newTestAccount("bob", 0))
+	repo.Save("carol", newTestAccount("carol", 1000))
+	repo.Save("dave", newTestAccount("dave", 0))
+
+	if err := transferBetween("alice", "bob", 100, "shared-key"); err != nil {
+		t.Fatalf("alice->bob transfer: %v", err)
+	}
+	if err := transferBetween("carol", "dave", 100, "shared-key"); err != nil {
+		t.Fatalf("carol->dave transfer: %v", err)
+	}
+
+	bob, _ := repo.Get("bob")
+	if got, want := bob.CheckBalance(), 100.0; got != want {
+		t.Errorf("bob balance = %.2f, want %.2f", got, want)
+	}
+	dave, _ := repo.Get("dave")
+	if got, want := dave.CheckBalance(), 100.0; got != want {
+		t.Errorf("dave balance = %.2f, want %.2f (a same-key collision with alice->bob must not skip this transfer)", got, want)
+	}
+}
+
+func TestTransferBetweenInsufficientFunds(t *testing.T) {
+	repo = NewInMemoryRepository()
+	repo.Save("alice", newTestAccount("alice", 10))
+	repo.Save("bob", newTestAccount("bob", 0))
+
+	if err := transferBetween("alice", "bob", 100, "key-fail"); err == nil {
+		t.Fatal("expected an error for an over-limit transfer, got nil")
+	}
+
+	alice, _ := repo.Get("alice")
+	if got, want := alice.CheckBalance(), 10.0; got != want {
+		t.Errorf("alice balance = %.2f, want %.2f (failed transfer should not move money)", got, want)
+	}
+}